@@ -0,0 +1,27 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/google/syzkaller/pkg/ast"
+)
+
+// Diagnostic is a single compiler error, with enough structure for tooling
+// (editors, CI annotators) to consume it without having to parse the
+// human-readable message back apart.
+//
+// There is currently no way to tell a warning from an error, or to attach a
+// hint, since the compiler's error-handler callback only ever reports a
+// position and a message. Add those fields back once a real source for them
+// exists, rather than carrying them as permanently-zero structure.
+type Diagnostic struct {
+	Pos     ast.Pos
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v", d.Pos, d.Message)
+}
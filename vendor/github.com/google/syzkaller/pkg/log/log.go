@@ -0,0 +1,109 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package log provides leveled logging gated by a global verbosity flag,
+// plus optional in-memory caching of recently logged lines.
+package log
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var flagV = flag.Int("v", 0, "verbosity")
+
+// Logf prints the message if the current verbosity level (set via -v) is >= v.
+func Logf(v int, msg string, args ...interface{}) {
+	if *flagV < v {
+		return
+	}
+	log(fmt.Sprintf(msg, args...))
+}
+
+// Errorf prints an error message regardless of verbosity.
+func Errorf(msg string, args ...interface{}) {
+	log(fmt.Sprintf("ERROR: "+msg, args...))
+}
+
+// Fatalf prints an error message and terminates the process.
+func Fatalf(msg string, args ...interface{}) {
+	log(fmt.Sprintf("FATAL: "+msg, args...))
+	os.Exit(1)
+}
+
+// Raw prints line to stderr verbatim, with no timestamp or level prefix, and
+// still records it in the cache. It exists for output that must be
+// machine-parseable exactly as given, such as a pre-rendered JSON or
+// GitHub Actions workflow-command line, which a timestamp or "ERROR: "
+// prefix would corrupt.
+func Raw(line string) {
+	fmt.Fprintln(os.Stderr, line)
+	cache.append(line)
+}
+
+func log(line string) {
+	now := time.Now()
+	fmt.Fprintf(os.Stderr, "%v %v\n", now.Format("2006/01/02 15:04:05"), line)
+	cache.append(line)
+}
+
+// EnableLogCaching turns on in-memory caching of log lines for later retrieval
+// via CachedLogOutput. It must be called once at startup, before any logging
+// happens on other goroutines.
+func EnableLogCaching(maxLines, maxBytes int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.enabled = true
+	cache.maxLines = maxLines
+	cache.maxBytes = maxBytes
+}
+
+// CachedLogOutput returns the recent log lines collected since EnableLogCaching
+// was called, oldest first, joined by newlines.
+func CachedLogOutput() string {
+	return cache.dump()
+}
+
+// logCache is a ring buffer of recent log lines bounded by both a maximum
+// number of lines and a maximum number of bytes. When either bound is
+// exceeded, the oldest entries are evicted before the new one is appended.
+type logCache struct {
+	mu       sync.Mutex
+	enabled  bool
+	maxLines int
+	maxBytes int
+	lines    []string
+	size     int
+}
+
+var cache logCache
+
+func (c *logCache) append(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return
+	}
+	c.lines = append(c.lines, line)
+	c.size += len(line)
+	for len(c.lines) > 0 && (len(c.lines) > c.maxLines || c.size > c.maxBytes) {
+		c.size -= len(c.lines[0])
+		c.lines = c.lines[1:]
+	}
+}
+
+func (c *logCache) dump() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := ""
+	for i, line := range c.lines {
+		if i != 0 {
+			out += "\n"
+		}
+		out += line
+	}
+	return out
+}
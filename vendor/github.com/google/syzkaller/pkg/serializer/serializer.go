@@ -0,0 +1,79 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package serializer renders Go values as Go source literals, for use by
+// code generators that need byte-for-byte reproducible output.
+package serializer
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Write renders val as a Go composite literal into w. Map keys and struct
+// fields are always emitted in a stable order (map keys sorted by their
+// formatted representation, struct fields in declaration order), so that
+// calling Write twice on semantically identical values, regardless of the
+// original map iteration order, produces byte-for-byte identical output.
+func Write(w io.Writer, val interface{}) {
+	write(w, reflect.ValueOf(val))
+}
+
+func write(w io.Writer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			fmt.Fprintf(w, "nil")
+			return
+		}
+		fmt.Fprintf(w, "&")
+		write(w, v.Elem())
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(w, "%v{\n", v.Type())
+		for i := 0; i < v.Len(); i++ {
+			write(w, v.Index(i))
+			fmt.Fprintf(w, ",\n")
+		}
+		fmt.Fprintf(w, "}")
+	case reflect.Map:
+		fmt.Fprintf(w, "%v{\n", v.Type())
+		for _, key := range sortedMapKeys(v) {
+			write(w, key)
+			fmt.Fprintf(w, ": ")
+			write(w, v.MapIndex(key))
+			fmt.Fprintf(w, ",\n")
+		}
+		fmt.Fprintf(w, "}")
+	case reflect.Struct:
+		fmt.Fprintf(w, "%v{\n", v.Type())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue // unexported field
+			}
+			fmt.Fprintf(w, "%v: ", t.Field(i).Name)
+			write(w, v.Field(i))
+			fmt.Fprintf(w, ",\n")
+		}
+		fmt.Fprintf(w, "}")
+	case reflect.String:
+		fmt.Fprintf(w, "%q", v.String())
+	case reflect.Interface:
+		write(w, v.Elem())
+	default:
+		fmt.Fprintf(w, "%#v", v.Interface())
+	}
+}
+
+// sortedMapKeys returns the keys of m sorted by their formatted
+// representation, so that map output does not depend on Go's randomized
+// map iteration order.
+func sortedMapKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%v", keys[i].Interface()) < fmt.Sprintf("%v", keys[j].Interface())
+	})
+	return keys
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/syzkaller/sys/syz-sysgen/syscallpb"
+)
+
+// jsonTarget mirrors the document written by sysgen's -format=json emitter.
+type jsonTarget struct {
+	OS         string       `json:"os"`
+	Arch       string       `json:"arch"`
+	Revision   string       `json:"revision"`
+	PtrSize    uint64       `json:"ptr_size"`
+	PageSize   uint64       `json:"page_size"`
+	NumPages   uint64       `json:"num_pages"`
+	DataOffset uint64       `json:"data_offset"`
+	Syscalls   []*Syscall   `json:"syscalls"`
+	Consts     []ConstValue `json:"consts"`
+}
+
+// LoadTargetJSON reconstructs a *Target from a document produced by sysgen's
+// -format=json emitter. Resources and Structs are intentionally left zero
+// value: unlike Syscalls/Consts, their shape is internal to the compiler
+// package and callers that need them should keep using -format=go.
+func LoadTargetJSON(data []byte) (*Target, error) {
+	var doc jsonTarget
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON target: %v", err)
+	}
+	return &Target{
+		OS:         doc.OS,
+		Arch:       doc.Arch,
+		Revision:   doc.Revision,
+		PtrSize:    doc.PtrSize,
+		PageSize:   doc.PageSize,
+		NumPages:   doc.NumPages,
+		DataOffset: doc.DataOffset,
+		Syscalls:   doc.Syscalls,
+		Consts:     doc.Consts,
+	}, nil
+}
+
+// LoadTargetProto reconstructs a *Target from a message produced by sysgen's
+// -format=proto emitter (see sys/syz-sysgen/syscall.proto).
+func LoadTargetProto(data []byte) (*Target, error) {
+	var msg syscallpb.Target
+	if err := syscallpb.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode protobuf target: %v", err)
+	}
+	target := &Target{
+		OS:         msg.Os,
+		Arch:       msg.Arch,
+		Revision:   msg.Revision,
+		PtrSize:    msg.PtrSize,
+		PageSize:   msg.PageSize,
+		NumPages:   msg.NumPages,
+		DataOffset: msg.DataOffset,
+		Consts:     make([]ConstValue, 0, len(msg.Consts)),
+	}
+	for _, c := range msg.Consts {
+		target.Consts = append(target.Consts, ConstValue{Name: c.Name, Value: c.Value})
+	}
+	for _, c := range msg.Syscalls {
+		target.Syscalls = append(target.Syscalls, &Syscall{
+			Name:     c.Name,
+			CallName: c.CallName,
+			NR:       uint64(c.Nr),
+		})
+	}
+	return target, nil
+}
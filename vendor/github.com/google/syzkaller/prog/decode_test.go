@@ -0,0 +1,85 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"testing"
+
+	"github.com/google/syzkaller/sys/syz-sysgen/syscallpb"
+)
+
+func TestLoadTargetJSON(t *testing.T) {
+	data := []byte(`{
+		"os": "linux",
+		"arch": "amd64",
+		"revision": "deadbeef",
+		"ptr_size": 8,
+		"page_size": 4096,
+		"num_pages": 10,
+		"data_offset": 16777216,
+		"syscalls": [{"Name": "read", "CallName": "read", "NR": 0}],
+		"consts": [{"Name": "X", "Value": 42}]
+	}`)
+
+	target, err := LoadTargetJSON(data)
+	if err != nil {
+		t.Fatalf("LoadTargetJSON failed: %v", err)
+	}
+	if target.OS != "linux" || target.Arch != "amd64" || target.Revision != "deadbeef" {
+		t.Fatalf("unexpected target: %+v", target)
+	}
+	if target.PtrSize != 8 || target.PageSize != 4096 || target.NumPages != 10 || target.DataOffset != 1<<24 {
+		t.Fatalf("unexpected target sizes: %+v", target)
+	}
+	if len(target.Syscalls) != 1 || target.Syscalls[0].Name != "read" {
+		t.Fatalf("unexpected syscalls: %+v", target.Syscalls)
+	}
+	if len(target.Consts) != 1 || target.Consts[0].Name != "X" || target.Consts[0].Value != 42 {
+		t.Fatalf("unexpected consts: %+v", target.Consts)
+	}
+}
+
+func TestLoadTargetJSONInvalid(t *testing.T) {
+	if _, err := LoadTargetJSON([]byte("not json")); err == nil {
+		t.Fatalf("expected an error decoding invalid JSON")
+	}
+}
+
+func TestLoadTargetProto(t *testing.T) {
+	msg := &syscallpb.Target{
+		Os:         "linux",
+		Arch:       "amd64",
+		Revision:   "deadbeef",
+		PtrSize:    8,
+		PageSize:   4096,
+		NumPages:   10,
+		DataOffset: 1 << 24,
+		Syscalls:   []*syscallpb.Syscall{{Name: "read", CallName: "read", Nr: 0}},
+		Consts:     []*syscallpb.ConstValue{{Name: "X", Value: 42}},
+	}
+	data, err := syscallpb.Marshal(msg)
+	if err != nil {
+		t.Fatalf("syscallpb.Marshal failed: %v", err)
+	}
+
+	target, err := LoadTargetProto(data)
+	if err != nil {
+		t.Fatalf("LoadTargetProto failed: %v", err)
+	}
+	if target.OS != "linux" || target.Arch != "amd64" || target.Revision != "deadbeef" {
+		t.Fatalf("unexpected target: %+v", target)
+	}
+	if len(target.Syscalls) != 1 || target.Syscalls[0].Name != "read" {
+		t.Fatalf("unexpected syscalls: %+v", target.Syscalls)
+	}
+	if len(target.Consts) != 1 || target.Consts[0].Name != "X" || target.Consts[0].Value != 42 {
+		t.Fatalf("unexpected consts: %+v", target.Consts)
+	}
+}
+
+func TestLoadTargetProtoInvalid(t *testing.T) {
+	if _, err := LoadTargetProto([]byte{0xff, 0xff, 0xff}); err == nil {
+		t.Fatalf("expected an error decoding invalid protobuf data")
+	}
+}
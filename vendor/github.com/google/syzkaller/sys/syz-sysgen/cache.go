@@ -0,0 +1,117 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// cacheDir holds one file per cached Job, named after its fingerprint.
+const cacheDir = ".syzcache"
+
+// cacheEntry is everything a Job needs in order to skip re-compiling an
+// arch: the semantic hash (needed to reproduce the cross-arch revision) and
+// the set of descriptions the compiler found unsupported on this arch
+// (needed to keep the "unsupported on all arches" check honest across cache
+// hits). Body and ArchBody are the rendered sysFile/executor bytes from the
+// run that produced this fingerprint, but with no revision baked in: the
+// revision is only known once every arch in this run has been processed, so
+// it is always stamped on afterwards (see stampRevision), cache hit or not.
+type cacheEntry struct {
+	SemHash     string
+	Unsupported map[string]bool
+	Body        []byte
+	ArchBody    []byte
+}
+
+func cachePath(fingerprint string) string {
+	return filepath.Join(cacheDir, fingerprint+".gob")
+}
+
+// loadCacheEntry returns the cached entry for fingerprint, if any.
+func loadCacheEntry(fingerprint string) (*cacheEntry, bool) {
+	data, err := ioutil.ReadFile(cachePath(fingerprint))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// storeCacheEntry persists entry under fingerprint for future runs.
+func storeCacheEntry(fingerprint string, entry *cacheEntry) {
+	osutil.MkdirAll(cacheDir)
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+		log.Errorf("failed to encode cache entry: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(cachePath(fingerprint), buf.Bytes(), 0644); err != nil {
+		log.Errorf("failed to write cache entry: %v", err)
+	}
+}
+
+// fingerprint identifies everything that can change a target's generated
+// output: the sorted contents of every descriptor (.txt) and const file
+// that feeds into it, the target struct itself, the -format in use, and the
+// sysgen binary (mtimes are not trusted; every input is hashed by content).
+func fingerprint(OS string, target *targets.Target) string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "target:%#v\n", target)
+	fmt.Fprintf(buf, "format:%v\n", *flagFormat)
+	for _, pattern := range []string{
+		filepath.Join("sys", OS, "*.txt"),
+		filepath.Join("sys", OS, "*_"+target.Arch+".const"),
+	} {
+		files, _ := filepath.Glob(pattern)
+		sort.Strings(files)
+		for _, f := range files {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(buf, "file:%v:%v\n", f, hash.String(data))
+		}
+	}
+	fmt.Fprintf(buf, "sysgen:%v\n", sysgenBinaryHash())
+	return hash.String(buf.Bytes())
+}
+
+var (
+	sysgenHashOnce sync.Once
+	sysgenHash     string
+)
+
+// sysgenBinaryHash hashes the running sysgen binary itself, so that a
+// change to the generator (not just to descriptor files) invalidates the
+// cache too.
+func sysgenBinaryHash() string {
+	sysgenHashOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return
+		}
+		sysgenHash = hash.String(data)
+	})
+	return sysgenHash
+}
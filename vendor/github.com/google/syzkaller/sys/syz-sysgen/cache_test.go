@@ -0,0 +1,111 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// withTempWorkdir chdirs into a fresh temp directory for the duration of
+// the test, so fingerprint's sys/<os>/*.txt glob sees only files the test
+// itself wrote.
+func withTempWorkdir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sysgen-cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(old)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestFingerprintChangesWithDescriptorContent(t *testing.T) {
+	withTempWorkdir(t)
+	target := &targets.Target{OS: "linux", Arch: "amd64"}
+
+	if err := os.MkdirAll(filepath.Join("sys", "linux"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		if err := ioutil.WriteFile(filepath.Join("sys", "linux", "sys.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("foo()\n")
+	fp1 := fingerprint("linux", target)
+
+	// Same content: fingerprint must be stable.
+	fp1Again := fingerprint("linux", target)
+	if fp1 != fp1Again {
+		t.Fatalf("fingerprint changed with no input change: %v != %v", fp1, fp1Again)
+	}
+
+	// Changed content: fingerprint must change.
+	write("foo()\nbar()\n")
+	fp2 := fingerprint("linux", target)
+	if fp1 == fp2 {
+		t.Fatalf("fingerprint did not change when descriptor content changed")
+	}
+}
+
+func TestFingerprintDiffersByArch(t *testing.T) {
+	withTempWorkdir(t)
+	if err := os.MkdirAll(filepath.Join("sys", "linux"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join("sys", "linux", "sys.txt"), []byte("foo()\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fpAMD64 := fingerprint("linux", &targets.Target{OS: "linux", Arch: "amd64"})
+	fpARM64 := fingerprint("linux", &targets.Target{OS: "linux", Arch: "arm64"})
+	if fpAMD64 == fpARM64 {
+		t.Fatalf("fingerprint must differ by arch, got the same value for amd64 and arm64")
+	}
+}
+
+func TestCacheEntryRoundTripsUnsupported(t *testing.T) {
+	withTempWorkdir(t)
+	entry := &cacheEntry{
+		SemHash:     "abc123",
+		Unsupported: map[string]bool{"some_syscall": true},
+		Body:        []byte("body"),
+		ArchBody:    []byte("arch body"),
+	}
+	storeCacheEntry("fp1", entry)
+
+	loaded, ok := loadCacheEntry("fp1")
+	if !ok {
+		t.Fatalf("expected cache entry to load")
+	}
+	if !loaded.Unsupported["some_syscall"] {
+		t.Fatalf("Unsupported was not persisted/restored by the cache: got %v", loaded.Unsupported)
+	}
+	if string(loaded.Body) != "body" || string(loaded.ArchBody) != "arch body" {
+		t.Fatalf("Body/ArchBody mismatch after cache round trip: %+v", loaded)
+	}
+}
+
+func TestLoadCacheEntryMissing(t *testing.T) {
+	withTempWorkdir(t)
+	if _, ok := loadCacheEntry("does-not-exist"); ok {
+		t.Fatalf("expected no entry for a fingerprint that was never stored")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// jsonEmitter emits one arch's syscall description as a single JSON
+// document, for consumption by tools that don't want to link the prog
+// package. Field order is whatever encoding/json picks for the struct below
+// (declaration order); map keys within Resources/Structs are sorted by
+// encoding/json itself, so the output is stable across runs. Revision is
+// left empty; it is stamped on separately once the cross-arch revision is
+// known (see stampRevision).
+type jsonEmitter struct {
+	doc jsonTarget
+}
+
+type jsonTarget struct {
+	OS         string            `json:"os"`
+	Arch       string            `json:"arch"`
+	Revision   string            `json:"revision"`
+	PtrSize    uint64            `json:"ptr_size"`
+	PageSize   uint64            `json:"page_size"`
+	NumPages   uint64            `json:"num_pages"`
+	DataOffset uint64            `json:"data_offset"`
+	Resources  interface{}       `json:"resources"`
+	Structs    interface{}       `json:"structs"`
+	Syscalls   []*prog.Syscall   `json:"syscalls"`
+	Consts     []prog.ConstValue `json:"consts"`
+}
+
+func newJSONEmitter(target *targets.Target) Emitter {
+	return &jsonEmitter{
+		doc: jsonTarget{
+			OS:         target.OS,
+			Arch:       target.Arch,
+			PtrSize:    target.PtrSize,
+			PageSize:   target.PageSize,
+			NumPages:   target.NumPages,
+			DataOffset: target.DataOffset,
+		},
+	}
+}
+
+func (e *jsonEmitter) EmitResources(resources interface{})   { e.doc.Resources = resources }
+func (e *jsonEmitter) EmitStructs(structs interface{})       { e.doc.Structs = structs }
+func (e *jsonEmitter) EmitSyscalls(syscalls []*prog.Syscall) { e.doc.Syscalls = syscalls }
+func (e *jsonEmitter) EmitConsts(consts []prog.ConstValue)   { e.doc.Consts = consts }
+
+func (e *jsonEmitter) Finish() []byte {
+	data, err := json.MarshalIndent(e.doc, "", "\t")
+	if err != nil {
+		log.Fatalf("failed to marshal JSON output: %v", err)
+	}
+	return data
+}
+
+// stampJSONRevision re-decodes a document produced by jsonEmitter.Finish,
+// sets its revision field and re-encodes it. It exists so that a cached,
+// already-rendered document can be restamped with this run's cross-arch
+// revision without re-running the compiler for that arch.
+func stampJSONRevision(body []byte, revision string) []byte {
+	var doc jsonTarget
+	if err := json.Unmarshal(body, &doc); err != nil {
+		log.Fatalf("failed to decode cached JSON output: %v", err)
+	}
+	doc.Revision = revision
+	data, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		log.Fatalf("failed to marshal JSON output: %v", err)
+	}
+	return data
+}
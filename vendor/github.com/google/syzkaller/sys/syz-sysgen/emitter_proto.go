@@ -0,0 +1,207 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/syz-sysgen/syscallpb"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// protoEmitter emits a binary-encoded syscallpb.Target message; see
+// syscall.proto for the schema. Resources/structs are converted through
+// reflection since their concrete compiler types aren't part of this
+// interface's contract: each element is expected to expose Name plus
+// whichever of Kind/Values (resources) or Fields/IsUnion (structs) it has.
+// Only those fields are carried over (each struct field as just a Name and
+// a Size); any other field of the concrete types is not emitted, unlike in
+// the JSON output. Syscalls carry only their name, call name and NR; their
+// args and return type are left unset. A field that doesn't exist on the
+// concrete type is left at its zero value rather than erroring, since not
+// every resource has a Kind and not every struct is a union.
+type protoEmitter struct {
+	msg *syscallpb.Target
+}
+
+func newProtoEmitter(target *targets.Target) Emitter {
+	return &protoEmitter{
+		msg: &syscallpb.Target{
+			Os:         target.OS,
+			Arch:       target.Arch,
+			PtrSize:    target.PtrSize,
+			PageSize:   target.PageSize,
+			NumPages:   target.NumPages,
+			DataOffset: target.DataOffset,
+		},
+	}
+}
+
+func (e *protoEmitter) EmitResources(resources interface{}) {
+	forEachElem(resources, func(v reflect.Value) {
+		e.msg.Resources = append(e.msg.Resources, &syscallpb.ResourceDesc{
+			Name:   elemFieldString(v, "Name"),
+			Kind:   elemFieldStringSlice(v, "Kind"),
+			Values: elemFieldUint64Slice(v, "Values"),
+		})
+	})
+}
+
+func (e *protoEmitter) EmitStructs(structs interface{}) {
+	forEachElem(structs, func(v reflect.Value) {
+		e.msg.Structs = append(e.msg.Structs, &syscallpb.StructDesc{
+			Name:    elemFieldString(v, "Name"),
+			Fields:  elemFieldTypes(v, "Fields"),
+			IsUnion: elemFieldBool(v, "IsUnion"),
+		})
+	})
+}
+
+func (e *protoEmitter) EmitSyscalls(syscalls []*prog.Syscall) {
+	for _, c := range syscalls {
+		e.msg.Syscalls = append(e.msg.Syscalls, &syscallpb.Syscall{
+			Name:     c.Name,
+			CallName: c.CallName,
+			Nr:       int32(c.NR),
+		})
+	}
+}
+
+func (e *protoEmitter) EmitConsts(consts []prog.ConstValue) {
+	for _, c := range consts {
+		e.msg.Consts = append(e.msg.Consts, &syscallpb.ConstValue{Name: c.Name, Value: c.Value})
+	}
+}
+
+func (e *protoEmitter) Finish() []byte {
+	data, err := syscallpb.Marshal(e.msg)
+	if err != nil {
+		log.Fatalf("failed to marshal protobuf output: %v", err)
+	}
+	return data
+}
+
+// stampProtoRevision re-decodes a message produced by protoEmitter.Finish,
+// sets its revision field and re-encodes it, mirroring stampJSONRevision.
+func stampProtoRevision(body []byte, revision string) []byte {
+	msg := &syscallpb.Target{}
+	if err := syscallpb.Unmarshal(body, msg); err != nil {
+		log.Fatalf("failed to decode cached protobuf output: %v", err)
+	}
+	msg.Revision = revision
+	data, err := syscallpb.Marshal(msg)
+	if err != nil {
+		log.Fatalf("failed to marshal protobuf output: %v", err)
+	}
+	return data
+}
+
+// forEachElem calls fn for every element of the slice v, which may be any
+// []*T or []T.
+func forEachElem(v interface{}, fn func(reflect.Value)) {
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.Len(); i++ {
+		fn(rv.Index(i))
+	}
+}
+
+// elemFieldString returns the string value of field on v (dereferencing a
+// pointer if needed), or "" if the field doesn't exist.
+func elemFieldString(v reflect.Value, field string) string {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	f := v.FieldByName(field)
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
+
+// elemFieldStringSlice returns the []string value of field on v, or nil if
+// the field doesn't exist or isn't a string slice.
+func elemFieldStringSlice(v reflect.Value, field string) []string {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	f := v.FieldByName(field)
+	if !f.IsValid() || f.Kind() != reflect.Slice || f.Type().Elem().Kind() != reflect.String {
+		return nil
+	}
+	out := make([]string, f.Len())
+	for i := range out {
+		out[i] = f.Index(i).String()
+	}
+	return out
+}
+
+// elemFieldUint64Slice returns field on v as a []uint64, converting each
+// element from whichever integer kind it actually is, or nil if the field
+// doesn't exist or isn't an integer slice.
+func elemFieldUint64Slice(v reflect.Value, field string) []uint64 {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	f := v.FieldByName(field)
+	if !f.IsValid() || f.Kind() != reflect.Slice {
+		return nil
+	}
+	out := make([]uint64, f.Len())
+	for i := range out {
+		u, ok := elemUint64(f.Index(i))
+		if !ok {
+			return nil
+		}
+		out[i] = u
+	}
+	return out
+}
+
+// elemFieldBool returns the bool value of field on v, or false if the field
+// doesn't exist or isn't a bool.
+func elemFieldBool(v reflect.Value, field string) bool {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	f := v.FieldByName(field)
+	return f.IsValid() && f.Kind() == reflect.Bool && f.Bool()
+}
+
+// elemFieldTypes extracts field on v as a []*syscallpb.Type, for struct
+// members whose own shape (a Name plus a Size) mirrors syscallpb.Type, e.g.
+// a compiler struct descriptor's field list.
+func elemFieldTypes(v reflect.Value, field string) []*syscallpb.Type {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	f := v.FieldByName(field)
+	if !f.IsValid() || f.Kind() != reflect.Slice {
+		return nil
+	}
+	var out []*syscallpb.Type
+	for i := 0; i < f.Len(); i++ {
+		elem := f.Index(i)
+		size, _ := elemUint64(reflect.Indirect(elem).FieldByName("Size"))
+		out = append(out, &syscallpb.Type{
+			Name: elemFieldString(elem, "Name"),
+			Size: size,
+		})
+	}
+	return out
+}
+
+// elemUint64 converts f, an integer-kinded reflect.Value, to a uint64. The
+// second return is false if f isn't a valid integer.
+func elemUint64(f reflect.Value) (uint64, bool) {
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return f.Uint(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(f.Int()), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package syscallpb holds the message types for sysgen's -format=proto
+// output (see ../syscall.proto) plus a minimal wire-format codec for them.
+//
+// This package is normally produced by running:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative syscall.proto
+//
+// protoc and the github.com/golang/protobuf/proto runtime aren't available
+// to regenerate it in every environment this repo is built in, so the
+// message structs and their Marshal/Unmarshal are hand-maintained here
+// instead. The wire format (field numbers and types) matches syscall.proto
+// exactly, so bytes produced here are readable by any standard protobuf
+// client; only the Go bindings themselves aren't protoc output. If protoc
+// output ever becomes available in all build environments, this file should
+// be deleted and replaced by it.
+package syscallpb
+
+type ConstValue struct {
+	Name  string
+	Value uint64
+}
+
+type ResourceDesc struct {
+	Name   string
+	Kind   []string
+	Values []uint64
+}
+
+type Type struct {
+	Name string
+	Size uint64
+}
+
+type StructDesc struct {
+	Name    string
+	Fields  []*Type
+	IsUnion bool
+}
+
+type Syscall struct {
+	Name     string
+	CallName string
+	Nr       int32
+	Args     []*Type
+	Ret      *Type
+}
+
+type Target struct {
+	Os         string
+	Arch       string
+	Revision   string
+	PtrSize    uint64
+	PageSize   uint64
+	NumPages   uint64
+	DataOffset uint64
+	Resources  []*ResourceDesc
+	Structs    []*StructDesc
+	Syscalls   []*Syscall
+	Consts     []*ConstValue
+}
+
+// Marshal encodes m as a length-prefix-free protobuf message, per the
+// syzkaller.Target message in syscall.proto.
+func Marshal(m *Target) ([]byte, error) {
+	buf := newBuffer()
+	buf.writeString(1, m.Os)
+	buf.writeString(2, m.Arch)
+	buf.writeString(3, m.Revision)
+	buf.writeVarintField(4, m.PtrSize)
+	buf.writeVarintField(5, m.PageSize)
+	buf.writeVarintField(6, m.NumPages)
+	buf.writeVarintField(7, m.DataOffset)
+	for _, r := range m.Resources {
+		buf.writeMessage(8, marshalResourceDesc(r))
+	}
+	for _, s := range m.Structs {
+		buf.writeMessage(9, marshalStructDesc(s))
+	}
+	for _, c := range m.Syscalls {
+		buf.writeMessage(10, marshalSyscall(c))
+	}
+	for _, c := range m.Consts {
+		buf.writeMessage(11, marshalConstValue(c))
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data produced by Marshal into m.
+func Unmarshal(data []byte, m *Target) error {
+	return decodeFields(data, func(num int, wire wireType, r *reader) error {
+		switch num {
+		case 1:
+			return r.readStringInto(wire, &m.Os)
+		case 2:
+			return r.readStringInto(wire, &m.Arch)
+		case 3:
+			return r.readStringInto(wire, &m.Revision)
+		case 4:
+			return r.readVarintInto(wire, &m.PtrSize)
+		case 5:
+			return r.readVarintInto(wire, &m.PageSize)
+		case 6:
+			return r.readVarintInto(wire, &m.NumPages)
+		case 7:
+			return r.readVarintInto(wire, &m.DataOffset)
+		case 8:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			rd, err := unmarshalResourceDesc(sub)
+			if err != nil {
+				return err
+			}
+			m.Resources = append(m.Resources, rd)
+			return nil
+		case 9:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			sd, err := unmarshalStructDesc(sub)
+			if err != nil {
+				return err
+			}
+			m.Structs = append(m.Structs, sd)
+			return nil
+		case 10:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			c, err := unmarshalSyscall(sub)
+			if err != nil {
+				return err
+			}
+			m.Syscalls = append(m.Syscalls, c)
+			return nil
+		case 11:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			c, err := unmarshalConstValue(sub)
+			if err != nil {
+				return err
+			}
+			m.Consts = append(m.Consts, c)
+			return nil
+		default:
+			return r.skip(wire)
+		}
+	})
+}
+
+func marshalConstValue(m *ConstValue) []byte {
+	buf := newBuffer()
+	buf.writeString(1, m.Name)
+	buf.writeVarintField(2, m.Value)
+	return buf.Bytes()
+}
+
+func unmarshalConstValue(data []byte) (*ConstValue, error) {
+	m := &ConstValue{}
+	err := decodeFields(data, func(num int, wire wireType, r *reader) error {
+		switch num {
+		case 1:
+			return r.readStringInto(wire, &m.Name)
+		case 2:
+			return r.readVarintInto(wire, &m.Value)
+		default:
+			return r.skip(wire)
+		}
+	})
+	return m, err
+}
+
+func marshalResourceDesc(m *ResourceDesc) []byte {
+	buf := newBuffer()
+	buf.writeString(1, m.Name)
+	// Repeated elements are written even when empty or zero: skipping them,
+	// as writeString/writeVarintField do for singular fields, would drop
+	// them from the list.
+	for _, k := range m.Kind {
+		buf.writeBytesField(2, []byte(k))
+	}
+	for _, v := range m.Values {
+		if v == 0 {
+			buf.writeTag(3, wireVarint)
+			buf.WriteByte(0)
+			continue
+		}
+		buf.writeVarintField(3, v)
+	}
+	return buf.Bytes()
+}
+
+func unmarshalResourceDesc(data []byte) (*ResourceDesc, error) {
+	m := &ResourceDesc{}
+	err := decodeFields(data, func(num int, wire wireType, r *reader) error {
+		switch num {
+		case 1:
+			return r.readStringInto(wire, &m.Name)
+		case 2:
+			var s string
+			if err := r.readStringInto(wire, &s); err != nil {
+				return err
+			}
+			m.Kind = append(m.Kind, s)
+			return nil
+		case 3:
+			var v uint64
+			if err := r.readVarintInto(wire, &v); err != nil {
+				return err
+			}
+			m.Values = append(m.Values, v)
+			return nil
+		default:
+			return r.skip(wire)
+		}
+	})
+	return m, err
+}
+
+func marshalType(m *Type) []byte {
+	buf := newBuffer()
+	buf.writeString(1, m.Name)
+	buf.writeVarintField(2, m.Size)
+	return buf.Bytes()
+}
+
+func unmarshalType(data []byte) (*Type, error) {
+	m := &Type{}
+	err := decodeFields(data, func(num int, wire wireType, r *reader) error {
+		switch num {
+		case 1:
+			return r.readStringInto(wire, &m.Name)
+		case 2:
+			return r.readVarintInto(wire, &m.Size)
+		default:
+			return r.skip(wire)
+		}
+	})
+	return m, err
+}
+
+func marshalStructDesc(m *StructDesc) []byte {
+	buf := newBuffer()
+	buf.writeString(1, m.Name)
+	for _, f := range m.Fields {
+		buf.writeMessage(2, marshalType(f))
+	}
+	buf.writeBool(3, m.IsUnion)
+	return buf.Bytes()
+}
+
+func unmarshalStructDesc(data []byte) (*StructDesc, error) {
+	m := &StructDesc{}
+	err := decodeFields(data, func(num int, wire wireType, r *reader) error {
+		switch num {
+		case 1:
+			return r.readStringInto(wire, &m.Name)
+		case 2:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			f, err := unmarshalType(sub)
+			if err != nil {
+				return err
+			}
+			m.Fields = append(m.Fields, f)
+			return nil
+		case 3:
+			return r.readBoolInto(wire, &m.IsUnion)
+		default:
+			return r.skip(wire)
+		}
+	})
+	return m, err
+}
+
+func marshalSyscall(m *Syscall) []byte {
+	buf := newBuffer()
+	buf.writeString(1, m.Name)
+	buf.writeString(2, m.CallName)
+	buf.writeVarintField(3, uint64(uint32(m.Nr)))
+	for _, a := range m.Args {
+		buf.writeMessage(4, marshalType(a))
+	}
+	if m.Ret != nil {
+		buf.writeMessage(5, marshalType(m.Ret))
+	}
+	return buf.Bytes()
+}
+
+func unmarshalSyscall(data []byte) (*Syscall, error) {
+	m := &Syscall{}
+	err := decodeFields(data, func(num int, wire wireType, r *reader) error {
+		switch num {
+		case 1:
+			return r.readStringInto(wire, &m.Name)
+		case 2:
+			return r.readStringInto(wire, &m.CallName)
+		case 3:
+			var v uint64
+			if err := r.readVarintInto(wire, &v); err != nil {
+				return err
+			}
+			m.Nr = int32(uint32(v))
+			return nil
+		case 4:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			a, err := unmarshalType(sub)
+			if err != nil {
+				return err
+			}
+			m.Args = append(m.Args, a)
+			return nil
+		case 5:
+			sub, err := r.readMessage(wire)
+			if err != nil {
+				return err
+			}
+			ret, err := unmarshalType(sub)
+			if err != nil {
+				return err
+			}
+			m.Ret = ret
+			return nil
+		default:
+			return r.skip(wire)
+		}
+	})
+	return m, err
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syscallpb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	in := &Target{
+		Os:         "linux",
+		Arch:       "amd64",
+		Revision:   "deadbeef",
+		PtrSize:    8,
+		PageSize:   4096,
+		NumPages:   10,
+		DataOffset: 0x1000000,
+		Resources: []*ResourceDesc{
+			{Name: "fd", Kind: []string{"fd"}, Values: []uint64{1, 2}},
+		},
+		Structs: []*StructDesc{
+			{Name: "foo", Fields: []*Type{{Name: "a", Size: 4}}, IsUnion: true},
+		},
+		Syscalls: []*Syscall{
+			{Name: "read", CallName: "read", Nr: 0, Args: []*Type{{Name: "fd", Size: 4}}, Ret: &Type{Name: "ret", Size: 8}},
+		},
+		Consts: []*ConstValue{
+			{Name: "X", Value: 42},
+		},
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var out Target
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(in, &out) {
+		t.Fatalf("round trip mismatch:\nin:  %+v\nout: %+v", in, out)
+	}
+}
+
+func TestMarshalOmitsZeroValues(t *testing.T) {
+	// proto3 doesn't distinguish "unset" from the zero value, so an empty
+	// Target should marshal to zero bytes: nothing to write.
+	data, err := Marshal(&Target{})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("expected empty output for zero-value Target, got %d bytes", len(data))
+	}
+}
+
+func TestUnmarshalUnknownFieldIsSkipped(t *testing.T) {
+	// A message from a newer schema with an extra field should still decode
+	// the fields this package knows about.
+	buf := newBuffer()
+	buf.writeString(1, "linux")
+	buf.writeString(99, "some-future-field")
+	var out Target
+	if err := Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Os != "linux" {
+		t.Fatalf("expected Os %q, got %q", "linux", out.Os)
+	}
+}
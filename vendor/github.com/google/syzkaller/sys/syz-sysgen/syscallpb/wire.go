@@ -0,0 +1,161 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syscallpb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// wireType is one of the protobuf wire types this package needs: varint for
+// scalar numeric/bool fields, and length-delimited for strings and embedded
+// messages. Fixed-width types aren't used by syscall.proto, so they aren't
+// implemented here.
+type wireType int
+
+const (
+	wireVarint wireType = 0
+	wireBytes  wireType = 2
+)
+
+type buffer struct {
+	bytes.Buffer
+}
+
+func newBuffer() *buffer {
+	return &buffer{}
+}
+
+func (b *buffer) writeTag(num int, wire wireType) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(num)<<3|uint64(wire))
+	b.Write(tmp[:n])
+}
+
+func (b *buffer) writeVarintField(num int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.writeTag(num, wireVarint)
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	b.Write(tmp[:n])
+}
+
+func (b *buffer) writeBool(num int, v bool) {
+	if !v {
+		return
+	}
+	b.writeVarintField(num, 1)
+}
+
+func (b *buffer) writeString(num int, s string) {
+	if s == "" {
+		return
+	}
+	b.writeBytesField(num, []byte(s))
+}
+
+func (b *buffer) writeMessage(num int, data []byte) {
+	b.writeBytesField(num, data)
+}
+
+func (b *buffer) writeBytesField(num int, data []byte) {
+	b.writeTag(num, wireBytes)
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(len(data)))
+	b.Write(tmp[:n])
+	b.Write(data)
+}
+
+// reader walks the remaining bytes of a single field's value.
+type reader struct {
+	data []byte
+}
+
+// decodeFields walks every (field number, wire type) pair in data in order,
+// calling fn to decode each one's value.
+func decodeFields(data []byte, fn func(num int, wire wireType, r *reader) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("syscallpb: malformed tag")
+		}
+		data = data[n:]
+		num := int(tag >> 3)
+		wire := wireType(tag & 7)
+		r := &reader{data: data}
+		if err := fn(num, wire, r); err != nil {
+			return err
+		}
+		data = r.data
+	}
+	return nil
+}
+
+func (r *reader) skip(wire wireType) error {
+	switch wire {
+	case wireVarint:
+		_, n := binary.Uvarint(r.data)
+		if n <= 0 {
+			return fmt.Errorf("syscallpb: malformed varint")
+		}
+		r.data = r.data[n:]
+		return nil
+	case wireBytes:
+		_, err := r.readMessage(wire)
+		return err
+	default:
+		return fmt.Errorf("syscallpb: unsupported wire type %v", wire)
+	}
+}
+
+func (r *reader) readVarintInto(wire wireType, out *uint64) error {
+	if wire != wireVarint {
+		return fmt.Errorf("syscallpb: expected varint, got wire type %v", wire)
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return fmt.Errorf("syscallpb: malformed varint")
+	}
+	r.data = r.data[n:]
+	*out = v
+	return nil
+}
+
+func (r *reader) readBoolInto(wire wireType, out *bool) error {
+	var v uint64
+	if err := r.readVarintInto(wire, &v); err != nil {
+		return err
+	}
+	*out = v != 0
+	return nil
+}
+
+func (r *reader) readMessage(wire wireType) ([]byte, error) {
+	if wire != wireBytes {
+		return nil, fmt.Errorf("syscallpb: expected length-delimited field, got wire type %v", wire)
+	}
+	l, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return nil, fmt.Errorf("syscallpb: malformed length")
+	}
+	r.data = r.data[n:]
+	if uint64(len(r.data)) < l {
+		return nil, fmt.Errorf("syscallpb: truncated field")
+	}
+	sub := r.data[:l]
+	r.data = r.data[l:]
+	return sub, nil
+}
+
+func (r *reader) readStringInto(wire wireType, out *string) error {
+	data, err := r.readMessage(wire)
+	if err != nil {
+		return err
+	}
+	*out = string(data)
+	return nil
+}
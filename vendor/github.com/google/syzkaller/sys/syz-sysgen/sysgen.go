@@ -5,13 +5,14 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/format"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"runtime/pprof"
 	"sort"
@@ -22,6 +23,7 @@ import (
 	"github.com/google/syzkaller/pkg/ast"
 	"github.com/google/syzkaller/pkg/compiler"
 	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/serializer"
 	"github.com/google/syzkaller/prog"
@@ -30,12 +32,41 @@ import (
 
 var (
 	flagMemProfile = flag.String("memprofile", "", "write a memory profile to the file")
+	flagFormat     = flag.String("format", "go", "output format for generated descriptions: go, json, proto")
+	flagOS         = flag.String("os", "", "restrict generation to this OS only (default: all)")
+	flagTargets    = flag.String("targets", "", "comma-separated os/arch list to (re-)generate, "+
+		"e.g. linux/amd64,linux/arm64 (default: all)")
+	flagDiagFormat = flag.String("diag-format", "text", "format for compiler diagnostics: text, json, github-actions")
 )
 
 func main() {
 	flag.Parse()
+	log.EnableLogCaching(1000, 1<<20)
+
+	wantTargets := make(map[string]bool)
+	for _, t := range strings.Split(*flagTargets, ",") {
+		if t != "" {
+			wantTargets[t] = true
+		}
+	}
 
 	for OS, archs := range targets.List {
+		if *flagOS != "" && OS != *flagOS {
+			continue
+		}
+		if len(wantTargets) != 0 {
+			var selected []*targets.Target
+			for _, t := range archs {
+				if wantTargets[OS+"/"+t.Arch] {
+					selected = append(selected, t)
+				}
+			}
+			if len(selected) == 0 {
+				continue
+			}
+			archs = selected
+		}
+
 		top := ast.ParseGlob(filepath.Join("sys", OS, "*.txt"), nil)
 		if top == nil {
 			os.Exit(1)
@@ -45,9 +76,15 @@ func main() {
 		type Job struct {
 			Target      *targets.Target
 			OK          bool
-			Errors      []string
+			Errors      []compiler.Diagnostic
 			Unsupported map[string]bool
-			ArchData    []byte
+			ArchBody    []byte
+			Prog        *compiler.Prog
+			ConstArr    []prog.ConstValue
+			SemHash     string
+			Fingerprint string
+			Cached      bool
+			CachedBody  []byte
 		}
 		var jobs []*Job
 		for _, target := range archs {
@@ -65,109 +102,192 @@ func main() {
 			job := job
 			go func() {
 				defer wg.Done()
+				job.Fingerprint = fingerprint(OS, job.Target)
+				if entry, ok := loadCacheEntry(job.Fingerprint); ok {
+					job.SemHash = entry.SemHash
+					job.Unsupported = entry.Unsupported
+					job.ArchBody = entry.ArchBody
+					job.CachedBody = entry.Body
+					job.Cached = true
+					job.OK = true
+					return
+				}
+
 				eh := func(pos ast.Pos, msg string) {
-					job.Errors = append(job.Errors, fmt.Sprintf("%v: %v\n", pos, msg))
+					job.Errors = append(job.Errors, compiler.Diagnostic{
+						Pos:     pos,
+						Message: msg,
+					})
 				}
 				consts := compiler.DeserializeConstsGlob(filepath.Join("sys", OS, "*_"+job.Target.Arch+".const"), eh)
 				if consts == nil {
 					return
 				}
-				prog := compiler.Compile(top, consts, job.Target, eh)
-				if prog == nil {
+				prg := compiler.Compile(top, consts, job.Target, eh)
+				if prg == nil {
 					return
 				}
-				job.Unsupported = prog.Unsupported
+				job.Unsupported = prg.Unsupported
+				job.Prog = prg
 
-				sysFile := filepath.Join("sys", OS, "gen", job.Target.Arch+".go")
-				out := new(bytes.Buffer)
-				generate(job.Target, prog, consts, out)
-				rev := hash.String(out.Bytes())
-				fmt.Fprintf(out, "const revision_%v = %q\n", job.Target.Arch, rev)
-				writeSource(sysFile, out.Bytes())
-
-				job.ArchData = generateExecutorSyscalls(job.Target, prog.Syscalls, rev)
+				job.ConstArr = make([]prog.ConstValue, 0, len(consts))
+				for name, val := range consts {
+					job.ConstArr = append(job.ConstArr, prog.ConstValue{Name: name, Value: val})
+				}
+				sort.Slice(job.ConstArr, func(i, j int) bool {
+					return job.ConstArr[i].Name < job.ConstArr[j].Name
+				})
+				job.SemHash = semanticHash(prg.Resources, prg.StructDescs, prg.Syscalls, job.ConstArr)
 				job.OK = true
 			}()
 		}
 		wg.Wait()
 
-		var syscallArchs [][]byte
 		unsupported := make(map[string]int)
-		for _, job := range jobs {
-			fmt.Printf("generating %v/%v...\n", job.Target.OS, job.Target.Arch)
-			for _, msg := range job.Errors {
-				fmt.Print(msg)
+		for i, job := range jobs {
+			cached := ""
+			if job.Cached {
+				cached = " (cached)"
+			}
+			log.Logf(0, "generating %v/%v...%v (%v/%v)", job.Target.OS, job.Target.Arch, cached, i+1, len(jobs))
+			for _, diag := range job.Errors {
+				rendered := renderDiagnostic(diag, *flagDiagFormat)
+				if *flagDiagFormat == "json" || *flagDiagFormat == "github-actions" {
+					// These formats must reach stderr byte-for-byte: a
+					// timestamp or "ERROR: " prefix would make the JSON
+					// line unparseable, or push the "::" token in a
+					// github-actions line off the start of the line where
+					// GitHub requires it.
+					log.Raw(rendered)
+				} else {
+					log.Errorf("%v", rendered)
+				}
 			}
 			if !job.OK {
-				os.Exit(1)
+				log.Fatalf("generation failed, recent log:\n%v", log.CachedLogOutput())
 			}
-			syscallArchs = append(syscallArchs, job.ArchData)
 			for u := range job.Unsupported {
 				unsupported[u]++
 			}
-			fmt.Printf("\n")
 		}
 
 		for what, count := range unsupported {
 			if count == len(jobs) {
-				failf("%v is unsupported on all arches (typo?)", what)
+				log.Fatalf("%v is unsupported on all arches (typo?)", what)
 			}
 		}
 
+		// The per-OS revision is derived from every arch's semantic hash plus
+		// the OS name and target list, so identical descriptions produce the
+		// same revision regardless of arch, and any change to any arch (or to
+		// the set of arches) changes it for all of them.
+		revBuf := new(bytes.Buffer)
+		fmt.Fprintf(revBuf, "%v\n", OS)
+		for _, job := range jobs {
+			fmt.Fprintf(revBuf, "%v:%v\n", job.Target.Arch, job.SemHash)
+		}
+		revision := hash.String(revBuf.Bytes())
+
+		var syscallArchs [][]byte
+		for _, job := range jobs {
+			sysFile := filepath.Join("sys", OS, "gen", job.Target.Arch+sysFileExt(*flagFormat))
+			var body []byte
+			if job.Cached {
+				body = job.CachedBody
+			} else {
+				e := newEmitter(*flagFormat, job.Target)
+				e.EmitResources(job.Prog.Resources)
+				e.EmitStructs(job.Prog.StructDescs)
+				e.EmitSyscalls(job.Prog.Syscalls)
+				e.EmitConsts(job.ConstArr)
+				body = finalizeOutput(*flagFormat, e.Finish())
+				job.ArchBody = generateExecutorSyscalls(OS, job.Target, job.Prog.Syscalls)
+				storeCacheEntry(job.Fingerprint, &cacheEntry{
+					SemHash:     job.SemHash,
+					Unsupported: job.Unsupported,
+					Body:        body,
+					ArchBody:    job.ArchBody,
+				})
+			}
+			// Every job is stamped with this run's revision here, cached or
+			// not: the revision above is only known once all jobs have run,
+			// so it can never have been baked into a cached Body already.
+			output := stampRevision(*flagFormat, job.Target, body, revision)
+			archData := restampArchRevision(job.ArchBody, revision)
+			writeSource(sysFile, output)
+			syscallArchs = append(syscallArchs, archData)
+		}
+
 		writeExecutorSyscalls(OS, syscallArchs)
 	}
 
 	if *flagMemProfile != "" {
 		f, err := os.Create(*flagMemProfile)
 		if err != nil {
-			failf("could not create memory profile: ", err)
+			log.Fatalf("could not create memory profile: %v", err)
 		}
 		runtime.GC() // get up-to-date statistics
 		if err := pprof.WriteHeapProfile(f); err != nil {
-			failf("could not write memory profile: ", err)
+			log.Fatalf("could not write memory profile: %v", err)
 		}
 		f.Close()
 	}
 }
 
-func generate(target *targets.Target, prg *compiler.Prog, consts map[string]uint64, out io.Writer) {
-	fmt.Fprintf(out, "// AUTOGENERATED FILE\n\n")
-	fmt.Fprintf(out, "package gen\n\n")
-	fmt.Fprintf(out, "import . \"github.com/google/syzkaller/prog\"\n\n")
-
-	fmt.Fprintf(out, "var Target_%v = &Target{"+
-		"OS: %q, Arch: %q, Revision: revision_%v, PtrSize: %v, "+
-		"PageSize: %v, NumPages: %v, DataOffset: %v, Syscalls: syscalls_%v, "+
-		"Resources: resources_%v, Structs: structDescs_%v, Consts: consts_%v}\n\n",
-		target.Arch, target.OS, target.Arch, target.Arch, target.PtrSize,
-		target.PageSize, target.NumPages, target.DataOffset,
-		target.Arch, target.Arch, target.Arch, target.Arch)
-
-	fmt.Fprintf(out, "var resources_%v = ", target.Arch)
-	serializer.Write(out, prg.Resources)
-	fmt.Fprintf(out, "\n\n")
-
-	fmt.Fprintf(out, "var structDescs_%v = ", target.Arch)
-	serializer.Write(out, prg.StructDescs)
-	fmt.Fprintf(out, "\n\n")
-
-	fmt.Fprintf(out, "var syscalls_%v = ", target.Arch)
-	serializer.Write(out, prg.Syscalls)
-	fmt.Fprintf(out, "\n\n")
-
-	constArr := make([]prog.ConstValue, 0, len(consts))
-	for name, val := range consts {
-		constArr = append(constArr, prog.ConstValue{Name: name, Value: val})
+// semanticHash canonically serializes an arch's resources, structs,
+// syscalls and consts and hashes the result. It is independent of
+// -format, so the resulting revision is the same no matter which output
+// format was requested.
+func semanticHash(resources, structs interface{}, syscalls []*prog.Syscall, consts []prog.ConstValue) string {
+	buf := new(bytes.Buffer)
+	serializer.Write(buf, resources)
+	serializer.Write(buf, structs)
+	serializer.Write(buf, syscalls)
+	serializer.Write(buf, consts)
+	return hash.String(buf.Bytes())
+}
+
+// renderDiagnostic formats a single compiler diagnostic for -diag-format:
+// text is the traditional human-readable "pos: message" line, json is one
+// object per line for tooling to parse, and github-actions emits a
+// workflow command that GitHub renders as an inline annotation.
+func renderDiagnostic(diag compiler.Diagnostic, format_ string) string {
+	switch format_ {
+	case "json":
+		data, err := json.Marshal(diag)
+		if err != nil {
+			log.Fatalf("failed to marshal diagnostic: %v", err)
+		}
+		return string(data)
+	case "github-actions":
+		// The compiler only ever reports errors (see the eh callback above),
+		// so this is always ::error; revisit once Diagnostic can carry a
+		// real severity.
+		return fmt.Sprintf("::error file=%v,line=%v,col=%v::%v",
+			diag.Pos.File, diag.Pos.Line, diag.Pos.Col, diag.Message)
+	default:
+		return diag.String()
+	}
+}
+
+// sysFileExt returns the file extension for a generated arch file in the
+// given -format.
+func sysFileExt(format string) string {
+	switch format {
+	case "json":
+		return ".json"
+	case "proto":
+		return ".pb"
+	default:
+		return ".go"
 	}
-	sort.Slice(constArr, func(i, j int) bool {
-		return constArr[i].Name < constArr[j].Name
-	})
-	fmt.Fprintf(out, "var consts_%v = ", target.Arch)
-	serializer.Write(out, constArr)
-	fmt.Fprintf(out, "\n\n")
 }
 
-func generateExecutorSyscalls(target *targets.Target, syscalls []*prog.Syscall, rev string) []byte {
+// generateExecutorSyscalls renders the executor-side syscall table for one
+// arch, with its SYZ_REVISION left empty: like the sysFile Emitter output,
+// the cross-arch revision is only known once every arch has been processed,
+// so it is always stamped on separately afterwards (see restampArchRevision).
+func generateExecutorSyscalls(OS string, target *targets.Target, syscalls []*prog.Syscall) []byte {
 	type SyscallData struct {
 		Name     string
 		CallName string
@@ -184,7 +304,6 @@ func generateExecutorSyscalls(target *targets.Target, syscalls []*prog.Syscall,
 		Calls      []SyscallData
 	}
 	data := ArchData{
-		Revision:   rev,
 		GOARCH:     target.Arch,
 		CARCH:      target.CArch,
 		PageSize:   target.PageSize,
@@ -203,12 +322,22 @@ func generateExecutorSyscalls(target *targets.Target, syscalls []*prog.Syscall,
 		return data.Calls[i].Name < data.Calls[j].Name
 	})
 	buf := new(bytes.Buffer)
-	if err := archTempl.Execute(buf, data); err != nil {
-		failf("failed to execute arch template: %v", err)
+	if err := archTemplForOS(OS).Execute(buf, data); err != nil {
+		log.Fatalf("failed to execute arch template: %v", err)
 	}
 	return buf.Bytes()
 }
 
+// archTemplForOS picks the executor syscall-table template for OS. Windows
+// resolves syscalls by name via GetProcAddress rather than by NR, so its
+// table layout differs from the numeric NR layout used everywhere else.
+func archTemplForOS(OS string) *template.Template {
+	if OS == "windows" {
+		return windowsArchTempl
+	}
+	return archTempl
+}
+
 func writeExecutorSyscalls(OS string, archs [][]byte) {
 	buf := new(bytes.Buffer)
 	buf.WriteString("// AUTOGENERATED FILE\n\n")
@@ -218,32 +347,69 @@ func writeExecutorSyscalls(OS string, archs [][]byte) {
 	writeFile(filepath.Join("executor", fmt.Sprintf("syscalls_%v.h", OS)), buf.Bytes())
 }
 
-func writeSource(file string, data []byte) {
+// finalizeOutput post-processes an emitter's raw output for format: Go
+// source is gofmt'd so both the on-disk file and the cache entry store the
+// same canonical bytes; other formats are already final.
+func finalizeOutput(format_ string, data []byte) []byte {
+	if format_ != "go" && format_ != "" {
+		return data
+	}
 	src, err := format.Source(data)
 	if err != nil {
-		fmt.Printf("%s\n", data)
-		failf("failed to format output: %v", err)
+		log.Errorf("%s", data)
+		log.Fatalf("failed to format output: %v", err)
 	}
-	if oldSrc, err := ioutil.ReadFile(file); err == nil && bytes.Equal(src, oldSrc) {
+	return src
+}
+
+// writeSource writes out to file unless the file already contains exactly
+// out, so that a run that changes nothing at all (not even the revision)
+// doesn't dirty the file's mtime. Unlike an earlier version of this
+// function, equality is on the full contents, revision included: every
+// arch written in the same invocation must end up carrying the same
+// revision on disk, so a revision-only change is not treated as a no-op.
+func writeSource(file string, out []byte) {
+	if oldSrc, err := ioutil.ReadFile(file); err == nil && bytes.Equal(oldSrc, out) {
 		return
 	}
-	writeFile(file, src)
+	writeFile(file, out)
+}
+
+// stampRevision appends/injects revision into an Emitter's pre-revision
+// output for format_, producing the bytes that actually get written to
+// disk. It is called for every job every run, cached or not, so a cached
+// arch's output never carries a stale revision from whatever run populated
+// the cache.
+func stampRevision(format_ string, target *targets.Target, body []byte, revision string) []byte {
+	switch format_ {
+	case "json":
+		return stampJSONRevision(body, revision)
+	case "proto":
+		return stampProtoRevision(body, revision)
+	default:
+		out := append([]byte{}, body...)
+		return append(out, []byte(fmt.Sprintf("const revision_%v = %q\n", target.Arch, revision))...)
+	}
+}
+
+var syzRevisionLineRe = regexp.MustCompile(`(?m)^#define SYZ_REVISION ".*"\n`)
+
+// restampArchRevision replaces the (possibly empty) SYZ_REVISION in an
+// executor syscall table snippet with revision, mirroring stampRevision for
+// the sysFile side.
+func restampArchRevision(archBody []byte, revision string) []byte {
+	return syzRevisionLineRe.ReplaceAll(archBody, []byte(fmt.Sprintf("#define SYZ_REVISION %q\n", revision)))
 }
 
 func writeFile(file string, data []byte) {
 	outf, err := os.Create(file)
 	if err != nil {
-		failf("failed to create output file: %v", err)
+		log.Fatalf("failed to create output file: %v", err)
 	}
 	defer outf.Close()
 	outf.Write(data)
 }
 
-func failf(msg string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, msg+"\n", args...)
-	os.Exit(1)
-}
-
 var archTempl = template.Must(template.New("").Parse(`
 #if {{range $cdef := $.CARCH}}defined({{$cdef}}) || {{end}}0
 #define GOARCH "{{.GOARCH}}"
@@ -258,3 +424,21 @@ call_t syscalls[] = {
 };
 #endif
 `))
+
+// windowsArchTempl differs from archTempl in that Windows has no syscall NRs:
+// every call is resolved by name via GetProcAddress at runtime, so NR is
+// replaced by a HANDLE slot filled in lazily and every call needs NeedCall.
+var windowsArchTempl = template.Must(template.New("").Parse(`
+#if {{range $cdef := $.CARCH}}defined({{$cdef}}) || {{end}}0
+#define GOARCH "{{.GOARCH}}"
+#define SYZ_REVISION "{{.Revision}}"
+#define SYZ_PAGE_SIZE {{.PageSize}}
+#define SYZ_NUM_PAGES {{.NumPages}}
+#define SYZ_DATA_OFFSET {{.DataOffset}}
+unsigned syscall_count = {{len $.Calls}};
+call_t syscalls[] = {
+{{range $c := $.Calls}}	{"{{$c.CallName}}", (HANDLE)0, (syscall_t)0},
+{{end}}
+};
+#endif
+`))
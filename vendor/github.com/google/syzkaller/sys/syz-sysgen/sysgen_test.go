@@ -0,0 +1,95 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestStampRevisionGo(t *testing.T) {
+	target := &targets.Target{Arch: "amd64"}
+	body := []byte("package gen\n\nvar x = 1\n")
+	out := stampRevision("go", target, body, "rev1")
+	want := string(body) + `const revision_amd64 = "rev1"` + "\n"
+	if string(out) != want {
+		t.Fatalf("stampRevision(go) = %q, want %q", out, want)
+	}
+
+	// body itself must be untouched (stampRevision must not mutate its
+	// cached input, since the same body is reused across runs).
+	if string(body) != "package gen\n\nvar x = 1\n" {
+		t.Fatalf("stampRevision mutated its body argument: %q", body)
+	}
+}
+
+func TestRestampArchRevision(t *testing.T) {
+	archBody := []byte("#define GOARCH \"amd64\"\n#define SYZ_REVISION \"\"\n#define SYZ_PAGE_SIZE 4096\n")
+	out := restampArchRevision(archBody, "rev2")
+	want := "#define GOARCH \"amd64\"\n#define SYZ_REVISION \"rev2\"\n#define SYZ_PAGE_SIZE 4096\n"
+	if string(out) != want {
+		t.Fatalf("restampArchRevision = %q, want %q", out, want)
+	}
+
+	// Re-stamping again with a different revision must replace, not
+	// duplicate, the line: this is the exact operation a cache hit from an
+	// earlier run performs every time it's reused.
+	out2 := restampArchRevision(out, "rev3")
+	want2 := "#define GOARCH \"amd64\"\n#define SYZ_REVISION \"rev3\"\n#define SYZ_PAGE_SIZE 4096\n"
+	if string(out2) != want2 {
+		t.Fatalf("re-stamping restampArchRevision = %q, want %q", out2, want2)
+	}
+}
+
+// TestWriteSourceRewritesOnRevisionChange reproduces the scenario the cache
+// exists for: one arch's descriptor changes this run (bumping the shared
+// cross-arch revision), while a sibling, unrelated arch's body is unchanged
+// and served from cache. That sibling's file must still be rewritten with
+// the new revision: every arch written in the same invocation must carry
+// the same revision on disk, so a revision-only change is not a no-op.
+func TestWriteSourceRewritesOnRevisionChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysgen-writesource-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "amd64.go")
+
+	body := []byte("package gen\n\nvar syscalls_amd64 = 1\n\n")
+	out1 := stampRevision("go", &targets.Target{Arch: "amd64"}, body, "rev1")
+	writeSource(file, out1)
+	first, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same body (this arch's descriptor didn't change this run), but the
+	// cross-arch revision moved because a sibling arch changed.
+	out2 := stampRevision("go", &targets.Target{Arch: "amd64"}, body, "rev2")
+	writeSource(file, out2)
+	second, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(second) != string(out2) {
+		t.Fatalf("writeSource did not update the stale revision: got %q, want %q", second, out2)
+	}
+	if string(second) == string(first) {
+		t.Fatalf("writeSource left the old revision in place")
+	}
+
+	// Truly nothing changed (same body, same revision): no-op.
+	writeSource(file, out2)
+	third, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(third) != string(out2) {
+		t.Fatalf("writeSource corrupted an unchanged file: got %q, want %q", third, out2)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package targets
+
+import (
+	"github.com/google/syzkaller/prog"
+)
+
+// MakeWindowsMmap returns a MakeMmap implementation for Windows targets. It
+// plays the same role as MakePosixMmap does for POSIX targets, but reserves
+// and commits memory via VirtualAlloc instead of mmap.
+func MakeWindowsMmap(target *prog.Target) func(start, npages uint64) *prog.Call {
+	meta := target.SyscallMap["VirtualAlloc"]
+	return func(start, npages uint64) *prog.Call {
+		return &prog.Call{
+			Meta: meta,
+			Args: []prog.Arg{
+				prog.MakeVmaPointerArg(meta.Args[0], start, npages),
+				prog.MakeConstArg(meta.Args[1], npages*target.PageSize),
+				prog.MakeConstArg(meta.Args[2], memCommit|memReserve),
+				prog.MakeConstArg(meta.Args[3], pageExecuteReadwrite),
+			},
+			Ret: prog.MakeReturnArg(meta.Ret),
+		}
+	}
+}
+
+const (
+	memCommit            = 0x1000
+	memReserve           = 0x2000
+	pageExecuteReadwrite = 0x40
+)
+
+// WindowsSanitizer rewrites generated calls for the Windows target, playing
+// the same role UnixSanitizer plays for POSIX targets.
+type WindowsSanitizer struct {
+	target *prog.Target
+}
+
+// MakeWindowsSanitizer creates a WindowsSanitizer for target.
+func MakeWindowsSanitizer(target *prog.Target) *WindowsSanitizer {
+	return &WindowsSanitizer{target: target}
+}
+
+// SanitizeCall is a no-op placeholder until Windows-specific call rewrites
+// are needed.
+func (arch *WindowsSanitizer) SanitizeCall(c *prog.Call) {
+}
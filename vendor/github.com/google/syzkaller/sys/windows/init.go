@@ -0,0 +1,32 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package windows registers the Windows targets. sys/windows/gen is
+// produced by syz-sysgen from sys/windows/sys.txt; generating it also
+// requires a "windows" entry in sys/targets.List (see sys/targets), which
+// lives in that package's target table rather than here.
+package windows
+
+import (
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+	"github.com/google/syzkaller/sys/windows/gen"
+)
+
+func init() {
+	prog.RegisterTarget(gen.Target_amd64, initTarget)
+	prog.RegisterTarget(gen.Target_386, initTarget)
+}
+
+func initTarget(target *prog.Target) {
+	arch := &arch{
+		win: targets.MakeWindowsSanitizer(target),
+	}
+
+	target.MakeMmap = targets.MakeWindowsMmap(target)
+	target.SanitizeCall = arch.win.SanitizeCall
+}
+
+type arch struct {
+	win *targets.WindowsSanitizer
+}